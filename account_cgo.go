@@ -0,0 +1,543 @@
+//go:build !goolm
+
+package olm
+
+/*
+#cgo LDFLAGS: -L/usr/local/lib/libolm.so -lolm
+#include <olm/olm.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// publishedSep separates the libolm pickle from the side-channel we append to
+// it to track which one-time/fallback key IDs have already been published.
+// libolm has no notion of this itself, so it has to be carried alongside the
+// pickle it produces.
+const publishedSep = ";published="
+
+// errAccountClosed is returned by Account methods once Clear has released
+// the account's libolm state, instead of letting them dereference the now-
+// nil C pointer and crash the process.
+var errAccountClosed = Error("olm: account already cleared")
+
+// Account an olm account that stores the ed25519 and curve25519 secret keys
+type Account struct {
+	ptr *C.struct_OlmAccount
+
+	// buf is the backing memory olm_account() carved ptr out of. It's kept
+	// here (rather than just discarded after newAccount) so Clear has
+	// something to zero once the account is no longer needed.
+	buf []byte
+
+	// published tracks the IDs of one-time and fallback keys that have
+	// already been handed out. One-time keys are only added here by
+	// MarkKeysAsPublished, fallback keys only by MarkFallbackKeyAsPublished,
+	// so the two stay independent even though libolm itself flips both kinds
+	// of native "published" state via the same combined C call.
+	published map[string]bool
+}
+
+func newAccount() *Account {
+	buf := make([]byte, C.olm_account_size())
+
+	acc := &Account{
+		ptr:       C.olm_account(unsafe.Pointer(&buf[0])),
+		buf:       buf,
+		published: make(map[string]bool),
+	}
+
+	runtime.SetFinalizer(acc, (*Account).Clear)
+
+	return acc
+}
+
+// Clear wipes the account's secret key material from memory and releases
+// the libolm-side state. It is safe to call more than once. A finalizer
+// calls this automatically as a safety net, but long-lived processes should
+// call it explicitly as soon as an account is no longer needed rather than
+// waiting on the garbage collector.
+func (a *Account) Clear() error {
+	if a.ptr == nil {
+		return nil
+	}
+
+	C.olm_clear_account(a.ptr)
+	err := a.lastError()
+
+	for i := range a.buf {
+		a.buf[i] = 0
+	}
+
+	a.ptr = nil
+	runtime.SetFinalizer(a, nil)
+
+	return err
+}
+
+// NewAccount creates a new account with ed25519 and curve25519 secret keys
+func NewAccount() (*Account, error) {
+	acc := newAccount()
+
+	rlen := C.olm_create_account_random_length(acc.ptr)
+	rbuf := make([]byte, rlen)
+
+	_, err := rand.Read(rbuf)
+	if err != nil {
+		return nil, err
+	}
+
+	C.olm_create_account(
+		acc.ptr,
+		unsafe.Pointer(&rbuf[0]),
+		rlen,
+	)
+
+	return acc, acc.lastError()
+}
+
+// AccountFromKey reconstructs an olm account from existing ed25519 secret key
+func AccountFromKey(sk ed25519.PrivateKey) (*Account, error) {
+	// TODO : We would be better off converting the ed25519 key to curve25519
+	// and trying to implement the pickle/encoding format so there is a direct
+	// relation between the two keypairs.
+
+	acc := newAccount()
+	rlen := C.olm_create_account_random_length(acc.ptr)
+
+	seed := sk.Seed()
+	seed = append(seed, sk.Seed()...)
+
+	C.olm_create_account(
+		acc.ptr,
+		unsafe.Pointer(&seed[0]),
+		rlen,
+	)
+
+	return acc, acc.lastError()
+}
+
+// AccountFromPickle reconstructs an account from a pickle
+func AccountFromPickle(key string, pickle string) (*Account, error) {
+	acc := newAccount()
+
+	corePickle := pickle
+	if idx := strings.LastIndex(pickle, publishedSep); idx >= 0 {
+		var ids []string
+		if err := json.Unmarshal([]byte(pickle[idx+len(publishedSep):]), &ids); err == nil {
+			for _, id := range ids {
+				acc.published[id] = true
+			}
+			corePickle = pickle[:idx]
+		}
+	}
+
+	kbuf := []byte(key)
+	pbuf := []byte(corePickle)
+
+	C.olm_unpickle_account(
+		acc.ptr,
+		unsafe.Pointer(&kbuf[0]),
+		C.size_t(len(kbuf)),
+		unsafe.Pointer(&pbuf[0]),
+		C.size_t(len(pbuf)),
+	)
+
+	return acc, acc.lastError()
+}
+
+// Pickle encodes and encrypts an account to a string safe format. The IDs of
+// any one-time/fallback keys already marked as published are carried as a
+// JSON side-channel appended after the libolm pickle, since libolm itself has
+// no notion of publication state.
+func (a Account) Pickle(key string) (string, error) {
+	if a.ptr == nil {
+		return "", errAccountClosed
+	}
+
+	kbuf := []byte(key)
+	pbuf := make([]byte, C.olm_pickle_account_length(a.ptr))
+
+	// this returns a result we should probably inspect
+	C.olm_pickle_account(
+		a.ptr,
+		unsafe.Pointer(&kbuf[0]),
+		C.size_t(len(kbuf)),
+		unsafe.Pointer(&pbuf[0]),
+		C.size_t(len(pbuf)),
+	)
+
+	if err := a.lastError(); err != nil {
+		return "", err
+	}
+
+	if len(a.published) == 0 {
+		return string(pbuf), nil
+	}
+
+	ids := make([]string, 0, len(a.published))
+	for id := range a.published {
+		ids = append(ids, id)
+	}
+
+	side, err := json.Marshal(ids)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pbuf) + publishedSep + string(side), nil
+}
+
+// Sign signs a message with the accounts ed25519 secret key
+func (a Account) Sign(message []byte) ([]byte, error) {
+	if a.ptr == nil {
+		return nil, errAccountClosed
+	}
+
+	slen := C.olm_account_signature_length(a.ptr)
+	sbuf := make([]byte, slen)
+
+	C.olm_account_sign(
+		a.ptr,
+		unsafe.Pointer(&message[0]),
+		C.size_t(len(message)),
+		unsafe.Pointer(&sbuf[0]),
+		slen,
+	)
+
+	return sbuf, a.lastError()
+}
+
+// MaxOneTimeKeys returns the maximum amount of keys an account can hold
+func (a Account) MaxOneTimeKeys() (int, error) {
+	if a.ptr == nil {
+		return 0, errAccountClosed
+	}
+
+	return int(C.olm_account_max_number_of_one_time_keys(a.ptr)), nil
+}
+
+// MarkKeysAsPublished marks the current set of one time keys as published.
+// libolm's own "published" flag also covers the fallback key (see
+// MarkFallbackKeyAsPublished), but the Go-side bookkeeping only records the
+// one-time keys here, so generating a fresh fallback key and then uploading
+// just a batch of one-time keys doesn't accidentally mark that fallback key
+// as published too.
+func (a Account) MarkKeysAsPublished() error {
+	if a.ptr == nil {
+		return errAccountClosed
+	}
+
+	// Record the IDs first: once libolm marks the keys as published it no
+	// longer lists them in olm_account_one_time_keys.
+	a.recordPublishedOneTimeKeyIDs()
+	C.olm_account_mark_keys_as_published(a.ptr)
+
+	return nil
+}
+
+// recordPublishedOneTimeKeyIDs records the IDs of the account's current
+// one-time keys as published, so UnpublishedOneTimeKeys can filter them out
+// later. Errors are ignored: worst case a key gets offered for upload again.
+func (a Account) recordPublishedOneTimeKeyIDs() {
+	olen := C.olm_account_one_time_keys_length(a.ptr)
+	obuf := make([]byte, olen)
+	C.olm_account_one_time_keys(a.ptr, unsafe.Pointer(&obuf[0]), olen)
+	a.recordPublishedIDs(obuf)
+}
+
+// recordPublishedFallbackID records the ID of the account's current fallback
+// key as published, so UnpublishedFallbackKey can filter it out later.
+// Errors are ignored: worst case the key gets offered for upload again.
+func (a Account) recordPublishedFallbackID() {
+	flen := C.olm_account_fallback_key_length(a.ptr)
+	fbuf := make([]byte, flen)
+	C.olm_account_fallback_key(a.ptr, unsafe.Pointer(&fbuf[0]), flen)
+	a.recordPublishedIDs(fbuf)
+}
+
+// recordPublishedIDs parses a `{"algorithm": {"keyId": "key"}}` one-time-key
+// style JSON blob and records every key ID it contains as published.
+func (a Account) recordPublishedIDs(raw []byte) {
+	if a.lastError() != nil {
+		return
+	}
+
+	var all map[string]map[string]string
+	if json.Unmarshal(raw, &all) != nil {
+		return
+	}
+
+	for _, keys := range all {
+		for id := range keys {
+			a.published[id] = true
+		}
+	}
+}
+
+// GenerateOneTimeKeys Generate a number of new one-time keys.
+// If the total number of keys stored by this account exceeds
+// max_one_time_keys() then the old keys are discarded
+func (a Account) GenerateOneTimeKeys(count int) error {
+	if a.ptr == nil {
+		return errAccountClosed
+	}
+
+	rlen := C.olm_account_generate_one_time_keys_random_length(
+		a.ptr,
+		C.size_t(count),
+	)
+
+	rbuf := make([]byte, rlen)
+
+	_, err := rand.Read(rbuf)
+	if err != nil {
+		return err
+	}
+
+	C.olm_account_generate_one_time_keys(
+		a.ptr,
+		C.size_t(count),
+		unsafe.Pointer(&rbuf[0]),
+		rlen,
+	)
+
+	return a.lastError()
+}
+
+// OneTimeKeys returns the pulic component of the accounts one time keys
+func (a Account) OneTimeKeys() (*OneTimeKeys, error) {
+	if a.ptr == nil {
+		return nil, errAccountClosed
+	}
+
+	var otk OneTimeKeys
+
+	olen := C.olm_account_one_time_keys_length(a.ptr)
+	obuf := make([]byte, olen)
+
+	C.olm_account_one_time_keys(
+		a.ptr,
+		unsafe.Pointer(&obuf[0]),
+		olen,
+	)
+
+	err := a.lastError()
+	if err != nil {
+		return nil, err
+	}
+
+	return &otk, json.Unmarshal(obuf, &otk)
+}
+
+// UnpublishedOneTimeKeys returns the public component of the one-time keys
+// that have not yet been marked as published via MarkKeysAsPublished, so
+// callers uploading to /keys/upload don't have to keep their own bookkeeping
+// of what's already been sent.
+func (a Account) UnpublishedOneTimeKeys() (*OneTimeKeys, error) {
+	if a.ptr == nil {
+		return nil, errAccountClosed
+	}
+
+	olen := C.olm_account_one_time_keys_length(a.ptr)
+	obuf := make([]byte, olen)
+
+	C.olm_account_one_time_keys(
+		a.ptr,
+		unsafe.Pointer(&obuf[0]),
+		olen,
+	)
+
+	if err := a.lastError(); err != nil {
+		return nil, err
+	}
+
+	return a.filterUnpublished(obuf)
+}
+
+// filterUnpublished parses a `{"algorithm": {"keyId": "key"}}` one-time-key
+// style JSON blob and strips out any key ID already recorded in a.published.
+func (a Account) filterUnpublished(raw []byte) (*OneTimeKeys, error) {
+	var all map[string]map[string]string
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+
+	unpublished := make(map[string]map[string]string, len(all))
+	for alg, keys := range all {
+		filtered := make(map[string]string, len(keys))
+		for id, key := range keys {
+			if !a.published[id] {
+				filtered[id] = key
+			}
+		}
+		unpublished[alg] = filtered
+	}
+
+	out, err := json.Marshal(unpublished)
+	if err != nil {
+		return nil, err
+	}
+
+	var otk OneTimeKeys
+	return &otk, json.Unmarshal(out, &otk)
+}
+
+// GenerateFallbackKey generates a new fallback one-time key. The previous
+// fallback key, if one exists, is retained until ForgetOldFallbackKey is
+// called, so pre-key messages encrypted against it can still be decrypted
+// while the rotation is in flight.
+func (a Account) GenerateFallbackKey() error {
+	if a.ptr == nil {
+		return errAccountClosed
+	}
+
+	rlen := C.olm_account_generate_fallback_key_random_length(a.ptr)
+	rbuf := make([]byte, rlen)
+
+	_, err := rand.Read(rbuf)
+	if err != nil {
+		return err
+	}
+
+	C.olm_account_generate_fallback_key(
+		a.ptr,
+		unsafe.Pointer(&rbuf[0]),
+		rlen,
+	)
+
+	return a.lastError()
+}
+
+// FallbackKey returns the public part of the current fallback key,
+// regardless of whether it has already been published.
+func (a Account) FallbackKey() (*OneTimeKeys, error) {
+	if a.ptr == nil {
+		return nil, errAccountClosed
+	}
+
+	var otk OneTimeKeys
+
+	olen := C.olm_account_fallback_key_length(a.ptr)
+	obuf := make([]byte, olen)
+
+	C.olm_account_fallback_key(
+		a.ptr,
+		unsafe.Pointer(&obuf[0]),
+		olen,
+	)
+
+	err := a.lastError()
+	if err != nil {
+		return nil, err
+	}
+
+	return &otk, json.Unmarshal(obuf, &otk)
+}
+
+// UnpublishedFallbackKey returns the public part of the fallback key, or an
+// empty key set if the current fallback key has already been marked as
+// published. This deliberately doesn't use libolm's own
+// olm_account_unpublished_fallback_key: that native flag is flipped by the
+// single combined olm_account_mark_keys_as_published call, so it would read
+// as "published" after a plain MarkKeysAsPublished even though the fallback
+// key was never handed out. The Go-side a.published set is the only thing
+// that tracks fallback publication independently of one-time-key
+// publication.
+func (a Account) UnpublishedFallbackKey() (*OneTimeKeys, error) {
+	if a.ptr == nil {
+		return nil, errAccountClosed
+	}
+
+	flen := C.olm_account_fallback_key_length(a.ptr)
+	fbuf := make([]byte, flen)
+
+	C.olm_account_fallback_key(
+		a.ptr,
+		unsafe.Pointer(&fbuf[0]),
+		flen,
+	)
+
+	if err := a.lastError(); err != nil {
+		return nil, err
+	}
+
+	return a.filterUnpublished(fbuf)
+}
+
+// MarkFallbackKeyAsPublished marks the current fallback key as published.
+// libolm only exposes a single combined olm_account_mark_keys_as_published
+// call that would also flip its native one-time-key "published" flag, so
+// this does not call it: only the fallback key's ID is recorded in the
+// Go-side bookkeeping, keeping the two independent (see MarkKeysAsPublished).
+func (a Account) MarkFallbackKeyAsPublished() error {
+	if a.ptr == nil {
+		return errAccountClosed
+	}
+
+	a.recordPublishedFallbackID()
+
+	return nil
+}
+
+// ForgetOldFallbackKey discards the previous fallback key. Until this is
+// called, messages encrypted against the previous fallback key can still be
+// decrypted, which allows pre-key messages sent just before a rotation to
+// keep working.
+func (a Account) ForgetOldFallbackKey() error {
+	if a.ptr == nil {
+		return errAccountClosed
+	}
+
+	C.olm_account_forget_old_fallback_key(a.ptr)
+
+	return a.lastError()
+}
+
+// RemoveOneTimeKeys removes a sessions one time keys from an account
+func (a Account) RemoveOneTimeKeys(s *Session) error {
+	if a.ptr == nil {
+		return errAccountClosed
+	}
+
+	C.olm_remove_one_time_keys(a.ptr, s.ptr)
+
+	return a.lastError()
+}
+
+// IdentityKeys returns the identity keys associated with the account
+func (a Account) IdentityKeys() (*PublicKeys, error) {
+	if a.ptr == nil {
+		return nil, errAccountClosed
+	}
+
+	var keys PublicKeys
+
+	olen := C.olm_account_identity_keys_length(a.ptr)
+	obuf := make([]byte, olen)
+
+	C.olm_account_identity_keys(
+		a.ptr,
+		unsafe.Pointer(&obuf[0]),
+		olen,
+	)
+
+	err := a.lastError()
+	if err != nil {
+		return nil, err
+	}
+
+	return &keys, json.Unmarshal(obuf, &keys)
+}
+
+func (a Account) lastError() error {
+	errStr := C.GoString(C.olm_account_last_error(a.ptr))
+	return Error(errStr)
+}
@@ -0,0 +1,60 @@
+//go:build !goolm
+
+package olm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// curve25519Count re-marshals otk and counts the curve25519 keys it carries,
+// without assuming anything about OneTimeKeys' field names.
+func curve25519Count(t *testing.T, otk *OneTimeKeys) int {
+	t.Helper()
+
+	raw, err := json.Marshal(otk)
+	require.Nil(t, err)
+
+	var decoded map[string]map[string]string
+	require.Nil(t, json.Unmarshal(raw, &decoded))
+
+	return len(decoded["curve25519"])
+}
+
+func TestMarkKeysAsPublishedDoesNotTouchFallbackKey(t *testing.T) {
+	acc, err := NewAccount()
+	require.Nil(t, err)
+
+	require.Nil(t, acc.GenerateFallbackKey())
+	require.Nil(t, acc.GenerateOneTimeKeys(1))
+
+	require.Nil(t, acc.MarkKeysAsPublished())
+
+	unpublishedOTK, err := acc.UnpublishedOneTimeKeys()
+	require.Nil(t, err)
+	require.Equal(t, 0, curve25519Count(t, unpublishedOTK))
+
+	unpublishedFallback, err := acc.UnpublishedFallbackKey()
+	require.Nil(t, err)
+	require.Equal(t, 1, curve25519Count(t, unpublishedFallback))
+}
+
+func TestMarkFallbackKeyAsPublishedDoesNotTouchOneTimeKeys(t *testing.T) {
+	acc, err := NewAccount()
+	require.Nil(t, err)
+
+	require.Nil(t, acc.GenerateFallbackKey())
+	require.Nil(t, acc.GenerateOneTimeKeys(1))
+
+	require.Nil(t, acc.MarkFallbackKeyAsPublished())
+
+	unpublishedFallback, err := acc.UnpublishedFallbackKey()
+	require.Nil(t, err)
+	require.Equal(t, 0, curve25519Count(t, unpublishedFallback))
+
+	unpublishedOTK, err := acc.UnpublishedOneTimeKeys()
+	require.Nil(t, err)
+	require.Equal(t, 1, curve25519Count(t, unpublishedOTK))
+}
@@ -0,0 +1,500 @@
+//go:build goolm
+
+package olm
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"runtime"
+)
+
+// defaultMaxOneTimeKeys mirrors libolm's own default limit on how many
+// one-time keys an account retains before the oldest are discarded.
+const defaultMaxOneTimeKeys = 100
+
+// Account is the pure-Go equivalent of the cgo/libolm backed Account's key
+// management surface: identity/one-time/fallback key generation, signing,
+// and pickling. It does not implement the Olm double ratchet itself — the
+// pure-Go Session in this tree is a stub with no ratchet state, and there is
+// no pure-Go GroupSession or Utility, so RemoveOneTimeKeys (which needs a
+// Session's state to key off) is a stub below.
+//
+// Its pickle format (accountPickleV1, see AccountFromPickle/Pickle) is a
+// bespoke JSON encoding, sealed with the same AES-256-CBC/HMAC-SHA256
+// envelope libolm's own pickles use (see goolm_pickle.go), but not libolm's
+// internal binary struct layout. So a pickle produced by this backend can
+// only be read back by this backend; it is not yet interchangeable with a
+// libolm pickle in either direction.
+//
+// X25519 private keys are kept as raw scalars rather than *ecdh.PrivateKey,
+// since crypto/ecdh doesn't expose a way to scrub a PrivateKey's internal
+// copy of its bytes; Clear needs something it can actually overwrite.
+type Account struct {
+	identityKey []byte // X25519 private scalar
+	signPub     ed25519.PublicKey
+	signPriv    ed25519.PrivateKey
+
+	oneTimeKeys map[string][]byte // X25519 private scalars, by key ID
+	otkOrder    []string
+	nextKeyID   uint32
+
+	fallbackKey     []byte // X25519 private scalar
+	fallbackKeyID   string
+	prevFallbackKey []byte // X25519 private scalar
+	prevFallbackID  string
+
+	published map[string]bool
+	closed    bool
+}
+
+// errAccountClosed is returned by Account methods once Clear has released
+// the account's key material, matching the cgo backend's guard against
+// using an account after it's been cleared.
+var errAccountClosed = errors.New("olm: account already cleared")
+
+func newAccount() *Account {
+	acc := &Account{
+		oneTimeKeys: make(map[string][]byte),
+		published:   make(map[string]bool),
+	}
+
+	runtime.SetFinalizer(acc, (*Account).Clear)
+
+	return acc
+}
+
+// Clear wipes the account's secret key material from memory. It is safe to
+// call more than once. A finalizer calls this automatically as a safety
+// net, but long-lived processes should call it explicitly as soon as an
+// account is no longer needed rather than waiting on the garbage collector.
+func (a *Account) Clear() error {
+	for i := range a.signPriv {
+		a.signPriv[i] = 0
+	}
+
+	for i := range a.identityKey {
+		a.identityKey[i] = 0
+	}
+
+	for _, priv := range a.oneTimeKeys {
+		for i := range priv {
+			priv[i] = 0
+		}
+	}
+
+	for i := range a.fallbackKey {
+		a.fallbackKey[i] = 0
+	}
+
+	for i := range a.prevFallbackKey {
+		a.prevFallbackKey[i] = 0
+	}
+
+	a.signPriv = nil
+	a.signPub = nil
+	a.identityKey = nil
+	a.oneTimeKeys = nil
+	a.otkOrder = nil
+	a.fallbackKey = nil
+	a.prevFallbackKey = nil
+	a.closed = true
+
+	runtime.SetFinalizer(a, nil)
+
+	return nil
+}
+
+// x25519PublicBytes derives the public key bytes for an X25519 private
+// scalar, validating it along the way.
+func x25519PublicBytes(priv []byte) ([]byte, error) {
+	k, err := ecdh.X25519().NewPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.PublicKey().Bytes(), nil
+}
+
+// NewAccount creates a new account with ed25519 and curve25519 secret keys
+func NewAccount() (*Account, error) {
+	acc := newAccount()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	acc.signPub, acc.signPriv = pub, priv
+
+	idKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	acc.identityKey = idKey.Bytes()
+
+	return acc, nil
+}
+
+// AccountFromKey reconstructs an olm account from existing ed25519 secret key
+func AccountFromKey(sk ed25519.PrivateKey) (*Account, error) {
+	acc := newAccount()
+	acc.signPriv = append(ed25519.PrivateKey(nil), sk...)
+	acc.signPub = append(ed25519.PublicKey(nil), sk.Public().(ed25519.PublicKey)...)
+
+	seed := sha512.Sum512(sk.Seed())
+	idKey, err := ecdh.X25519().NewPrivateKey(seed[:32])
+	if err != nil {
+		return nil, err
+	}
+	acc.identityKey = idKey.Bytes()
+
+	return acc, nil
+}
+
+// accountPickleV1 is the plaintext payload encrypted by goolmPickle.
+type accountPickleV1 struct {
+	SignPriv         []byte
+	IdentityPriv     []byte
+	OneTimeKeys      map[string][]byte
+	OTKOrder         []string
+	NextKeyID        uint32
+	FallbackPriv     []byte
+	FallbackKeyID    string
+	PrevFallbackPriv []byte
+	PrevFallbackID   string
+	Published        []string
+}
+
+// AccountFromPickle reconstructs an account from a pickle
+func AccountFromPickle(key string, pickle string) (*Account, error) {
+	raw, err := goolmUnpickle([]byte(key), pickle)
+	if err != nil {
+		return nil, err
+	}
+
+	var data accountPickleV1
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	acc := newAccount()
+	acc.signPriv = data.SignPriv
+	acc.signPub = append(ed25519.PublicKey(nil), acc.signPriv.Public().(ed25519.PublicKey)...)
+	acc.nextKeyID = data.NextKeyID
+	acc.otkOrder = data.OTKOrder
+
+	if _, err := ecdh.X25519().NewPrivateKey(data.IdentityPriv); err != nil {
+		return nil, err
+	}
+	acc.identityKey = data.IdentityPriv
+
+	for id, priv := range data.OneTimeKeys {
+		if _, err := ecdh.X25519().NewPrivateKey(priv); err != nil {
+			return nil, err
+		}
+		acc.oneTimeKeys[id] = priv
+	}
+
+	if len(data.FallbackPriv) > 0 {
+		if _, err := ecdh.X25519().NewPrivateKey(data.FallbackPriv); err != nil {
+			return nil, err
+		}
+		acc.fallbackKey = data.FallbackPriv
+		acc.fallbackKeyID = data.FallbackKeyID
+	}
+
+	if len(data.PrevFallbackPriv) > 0 {
+		if _, err := ecdh.X25519().NewPrivateKey(data.PrevFallbackPriv); err != nil {
+			return nil, err
+		}
+		acc.prevFallbackKey = data.PrevFallbackPriv
+		acc.prevFallbackID = data.PrevFallbackID
+	}
+
+	for _, id := range data.Published {
+		acc.published[id] = true
+	}
+
+	return acc, nil
+}
+
+// Pickle encodes and encrypts an account to a string safe format
+func (a *Account) Pickle(key string) (string, error) {
+	if a.closed {
+		return "", errAccountClosed
+	}
+
+	data := accountPickleV1{
+		SignPriv:         a.signPriv,
+		IdentityPriv:     a.identityKey,
+		OneTimeKeys:      a.oneTimeKeys,
+		OTKOrder:         a.otkOrder,
+		NextKeyID:        a.nextKeyID,
+		FallbackPriv:     a.fallbackKey,
+		FallbackKeyID:    a.fallbackKeyID,
+		PrevFallbackPriv: a.prevFallbackKey,
+		PrevFallbackID:   a.prevFallbackID,
+	}
+
+	for id := range a.published {
+		data.Published = append(data.Published, id)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	return goolmPickle([]byte(key), raw)
+}
+
+// Sign signs a message with the accounts ed25519 secret key
+func (a *Account) Sign(message []byte) ([]byte, error) {
+	if a.closed {
+		return nil, errAccountClosed
+	}
+
+	return ed25519.Sign(a.signPriv, message), nil
+}
+
+// MaxOneTimeKeys returns the maximum amount of keys an account can hold
+func (a *Account) MaxOneTimeKeys() (int, error) {
+	if a.closed {
+		return 0, errAccountClosed
+	}
+
+	return defaultMaxOneTimeKeys, nil
+}
+
+// MarkKeysAsPublished marks the current set of one time keys as published
+func (a *Account) MarkKeysAsPublished() error {
+	if a.closed {
+		return errAccountClosed
+	}
+
+	for id := range a.oneTimeKeys {
+		a.published[id] = true
+	}
+
+	return nil
+}
+
+// nextID allocates the next one-time/fallback key ID, matching the opaque
+// base64 counter IDs libolm itself hands out.
+func (a *Account) nextID() string {
+	var idBuf [4]byte
+	binary.BigEndian.PutUint32(idBuf[:], a.nextKeyID)
+	a.nextKeyID++
+
+	return base64.RawStdEncoding.EncodeToString(idBuf[:])
+}
+
+// GenerateOneTimeKeys Generate a number of new one-time keys.
+// If the total number of keys stored by this account exceeds
+// max_one_time_keys() then the old keys are discarded
+func (a *Account) GenerateOneTimeKeys(count int) error {
+	if a.closed {
+		return errAccountClosed
+	}
+
+	for i := 0; i < count; i++ {
+		priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+
+		id := a.nextID()
+		a.oneTimeKeys[id] = priv.Bytes()
+		a.otkOrder = append(a.otkOrder, id)
+	}
+
+	for len(a.otkOrder) > defaultMaxOneTimeKeys {
+		oldest := a.otkOrder[0]
+		a.otkOrder = a.otkOrder[1:]
+		delete(a.oneTimeKeys, oldest)
+	}
+
+	return nil
+}
+
+func (a *Account) oneTimeKeysJSON(ids map[string][]byte) (*OneTimeKeys, error) {
+	curve25519 := make(map[string]string, len(ids))
+	for id, priv := range ids {
+		pub, err := x25519PublicBytes(priv)
+		if err != nil {
+			return nil, err
+		}
+		curve25519[id] = base64.RawStdEncoding.EncodeToString(pub)
+	}
+
+	raw, err := json.Marshal(map[string]map[string]string{"curve25519": curve25519})
+	if err != nil {
+		return nil, err
+	}
+
+	var otk OneTimeKeys
+	return &otk, json.Unmarshal(raw, &otk)
+}
+
+// OneTimeKeys returns the pulic component of the accounts one time keys
+func (a *Account) OneTimeKeys() (*OneTimeKeys, error) {
+	if a.closed {
+		return nil, errAccountClosed
+	}
+
+	return a.oneTimeKeysJSON(a.oneTimeKeys)
+}
+
+// UnpublishedOneTimeKeys returns the public component of the one-time keys
+// that have not yet been marked as published via MarkKeysAsPublished.
+func (a *Account) UnpublishedOneTimeKeys() (*OneTimeKeys, error) {
+	if a.closed {
+		return nil, errAccountClosed
+	}
+
+	unpublished := make(map[string][]byte, len(a.oneTimeKeys))
+	for id, priv := range a.oneTimeKeys {
+		if !a.published[id] {
+			unpublished[id] = priv
+		}
+	}
+
+	return a.oneTimeKeysJSON(unpublished)
+}
+
+// RemoveOneTimeKeys removes a session's used one-time key from the account.
+// The pure-Go Session in this tree carries no ratchet state, so there is
+// nothing to key the removal off; no key is removed and a not-implemented
+// error is always returned.
+func (a *Account) RemoveOneTimeKeys(s *Session) error {
+	if a.closed {
+		return errAccountClosed
+	}
+
+	return errors.New("olm: RemoveOneTimeKeys is not implemented for the goolm backend yet")
+}
+
+// IdentityKeys returns the identity keys associated with the account
+func (a *Account) IdentityKeys() (*PublicKeys, error) {
+	if a.closed {
+		return nil, errAccountClosed
+	}
+
+	pub, err := x25519PublicBytes(a.identityKey)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(map[string]string{
+		"curve25519": base64.RawStdEncoding.EncodeToString(pub),
+		"ed25519":    base64.RawStdEncoding.EncodeToString(a.signPub),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys PublicKeys
+	return &keys, json.Unmarshal(raw, &keys)
+}
+
+// GenerateFallbackKey generates a new fallback one-time key. The previous
+// fallback key, if one exists, is retained until ForgetOldFallbackKey is
+// called, so pre-key messages encrypted against it can still be decrypted
+// while the rotation is in flight.
+func (a *Account) GenerateFallbackKey() error {
+	if a.closed {
+		return errAccountClosed
+	}
+
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	if a.fallbackKey != nil {
+		a.prevFallbackKey = a.fallbackKey
+		a.prevFallbackID = a.fallbackKeyID
+	}
+
+	a.fallbackKey = priv.Bytes()
+	a.fallbackKeyID = a.nextID()
+
+	return nil
+}
+
+func (a *Account) fallbackKeyJSON(includeIfPublished bool) (*OneTimeKeys, error) {
+	curve25519 := make(map[string]string)
+	if a.fallbackKey != nil && (includeIfPublished || !a.published[a.fallbackKeyID]) {
+		pub, err := x25519PublicBytes(a.fallbackKey)
+		if err != nil {
+			return nil, err
+		}
+		curve25519[a.fallbackKeyID] = base64.RawStdEncoding.EncodeToString(pub)
+	}
+
+	raw, err := json.Marshal(map[string]map[string]string{"curve25519": curve25519})
+	if err != nil {
+		return nil, err
+	}
+
+	var otk OneTimeKeys
+	return &otk, json.Unmarshal(raw, &otk)
+}
+
+// FallbackKey returns the public part of the current fallback key,
+// regardless of whether it has already been published.
+func (a *Account) FallbackKey() (*OneTimeKeys, error) {
+	if a.closed {
+		return nil, errAccountClosed
+	}
+
+	return a.fallbackKeyJSON(true)
+}
+
+// UnpublishedFallbackKey returns the public part of the fallback key, or an
+// empty key set if the current fallback key has already been marked as
+// published.
+func (a *Account) UnpublishedFallbackKey() (*OneTimeKeys, error) {
+	if a.closed {
+		return nil, errAccountClosed
+	}
+
+	return a.fallbackKeyJSON(false)
+}
+
+// MarkFallbackKeyAsPublished marks the current fallback key as published.
+func (a *Account) MarkFallbackKeyAsPublished() error {
+	if a.closed {
+		return errAccountClosed
+	}
+
+	if a.fallbackKeyID != "" {
+		a.published[a.fallbackKeyID] = true
+	}
+
+	return nil
+}
+
+// ForgetOldFallbackKey discards the previous fallback key. Until this is
+// called, messages encrypted against the previous fallback key can still be
+// decrypted, which allows pre-key messages sent just before a rotation to
+// keep working.
+func (a *Account) ForgetOldFallbackKey() error {
+	if a.closed {
+		return errAccountClosed
+	}
+
+	for i := range a.prevFallbackKey {
+		a.prevFallbackKey[i] = 0
+	}
+
+	a.prevFallbackKey = nil
+	a.prevFallbackID = ""
+
+	return nil
+}
@@ -0,0 +1,26 @@
+// Package olm implements Matrix/Olm accounts, sessions, and cryptographic
+// primitives, with a choice of two backends selected by the goolm build
+// tag: cgo/libolm (default, !goolm) and a pure-Go implementation (goolm).
+//
+// Backend parity status: the cgo backend is the complete implementation.
+// The goolm backend currently only covers Account's key management and
+// signing — there is no pure-Go double ratchet, so Session is a stub with
+// just a Clear/finalizer lifecycle, and there is no GroupSession or
+// Utility at all. goolm pickles are also not interoperable with libolm's;
+// see account_goolm.go and goolm_pickle.go for the details.
+//
+// selfid-net/self-crypto-go#chunk0-3 asked for "the same Account, Session,
+// GroupSession, Utility, and PublicKeys API… implemented by two backends"
+// including a full pure-Go double ratchet and cross-backend pickle
+// interop. Only the Account key-management slice of that has landed; the
+// ratchet/Session/GroupSession/Utility work and pickle interop remain open
+// and need their own follow-up request — goolm should not be treated as a
+// drop-in, cgo-free replacement for the libolm backend until they do.
+//
+// selfid-net/self-crypto-go#chunk0-4 asked to "change session-creation
+// APIs to take" a SessionConfig, defaulting new sessions to Olm v2. This
+// tree still has no session-creation API at all (see the chunk0-3 gap
+// above), so SessionConfig (session_config.go) is standalone plumbing:
+// nothing constructs a session with it yet. Also tracked as partially
+// delivered, pending session creation landing for either backend.
+package olm
@@ -0,0 +1,147 @@
+//go:build goolm
+
+package olm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// goolmPickle encrypts and MACs payload using the same envelope libolm's own
+// pickle format uses (see libolm's docs/Pickle.md): the supplied key is
+// expanded via HKDF-SHA256 into an AES-256 key, an HMAC-SHA256 key and an IV,
+// payload is AES-256-CBC encrypted, and the first 8 bytes of an HMAC-SHA256
+// over the ciphertext are appended before unpadded standard base64 encoding.
+// This matches libolm's outer encryption envelope, but not the plaintext
+// layout it encrypts (libolm serialises its internal struct layout; this
+// backend serialises accountPickleV1 as JSON, see account_goolm.go), so on
+// its own this does not make pickles interchangeable between backends.
+func goolmPickle(key, payload []byte) (string, error) {
+	aesKey, macKey, iv, err := pickleDeriveKeys(key)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return "", err
+	}
+
+	padded := pkcs7Pad(payload, block.BlockSize())
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ciphertext)
+	tag := mac.Sum(nil)[:8]
+
+	return base64.RawStdEncoding.EncodeToString(append(ciphertext, tag...)), nil
+}
+
+// goolmUnpickle reverses goolmPickle, verifying the MAC before decrypting.
+func goolmUnpickle(key []byte, pickled string) ([]byte, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(pickled)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) < 8 {
+		return nil, errors.New("olm: pickle too short")
+	}
+
+	ciphertext, tag := raw[:len(raw)-8], raw[len(raw)-8:]
+
+	aesKey, macKey, iv, err := pickleDeriveKeys(key)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil)[:8], tag) {
+		return nil, errors.New("olm: pickle MAC mismatch")
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errors.New("olm: pickle ciphertext is not block aligned")
+	}
+
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ciphertext)
+
+	return pkcs7Unpad(plain)
+}
+
+// pickleDeriveKeys expands the caller-supplied pickle key into an AES-256
+// key, an HMAC-SHA256 key and a CBC IV via HKDF-SHA256, mirroring libolm's
+// own key derivation for pickling.
+func pickleDeriveKeys(key []byte) (aesKey, macKey, iv []byte, err error) {
+	prk := hkdfExtract(nil, key)
+	okm := hkdfExpand(prk, []byte("Pickle"), 32+32+16)
+
+	return okm[:32], okm[32:64], okm[64:80], nil
+}
+
+func hkdfExtract(salt, ikm []byte) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+
+	return mac.Sum(nil)
+}
+
+func hkdfExpand(prk, info []byte, length int) []byte {
+	var (
+		out  []byte
+		prev []byte
+		ctr  byte = 1
+	)
+
+	for len(out) < length {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{ctr})
+
+		prev = mac.Sum(nil)
+		out = append(out, prev...)
+		ctr++
+	}
+
+	return out[:length]
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padLen := blockSize - len(data)%blockSize
+	padding := make([]byte, padLen)
+	for i := range padding {
+		padding[i] = byte(padLen)
+	}
+
+	return append(append([]byte{}, data...), padding...)
+}
+
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("olm: empty pickle payload")
+	}
+
+	padLen := int(data[len(data)-1])
+	if padLen == 0 || padLen > len(data) {
+		return nil, errors.New("olm: invalid pickle padding")
+	}
+
+	return data[:len(data)-padLen], nil
+}
@@ -0,0 +1,46 @@
+//go:build goolm
+
+package olm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoolmPickleRoundTrip(t *testing.T) {
+	plaintext := []byte(`{"hello":"world"}`)
+
+	pickled, err := goolmPickle([]byte("key"), plaintext)
+	require.Nil(t, err)
+
+	out, err := goolmUnpickle([]byte("key"), pickled)
+	require.Nil(t, err)
+	require.Equal(t, plaintext, out)
+}
+
+func TestGoolmUnpickleWrongKey(t *testing.T) {
+	pickled, err := goolmPickle([]byte("key"), []byte("secret"))
+	require.Nil(t, err)
+
+	_, err = goolmUnpickle([]byte("not the key"), pickled)
+	require.NotNil(t, err)
+}
+
+func TestGoolmAccountPickleRoundTrip(t *testing.T) {
+	acc, err := NewAccount()
+	require.Nil(t, err)
+	require.Nil(t, acc.GenerateOneTimeKeys(5))
+
+	pickled, err := acc.Pickle("passphrase")
+	require.Nil(t, err)
+
+	restored, err := AccountFromPickle("passphrase", pickled)
+	require.Nil(t, err)
+
+	otkBefore, err := acc.OneTimeKeys()
+	require.Nil(t, err)
+	otkAfter, err := restored.OneTimeKeys()
+	require.Nil(t, err)
+	require.Equal(t, otkBefore, otkAfter)
+}
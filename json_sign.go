@@ -0,0 +1,223 @@
+package olm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// SignJSON signs the canonical JSON encoding of obj with the account's
+// ed25519 key and returns the unpadded base64 signature as a JSON string.
+// The canonicalisation follows the Matrix/RFC 8785-style rules used for
+// device_keys and signed_curve25519 uploads: sorted object keys, no
+// insignificant whitespace, no unicode escapes for BMP characters, and the
+// top-level "signatures" and "unsigned" fields stripped before signing.
+func (a Account) SignJSON(obj any) (json.RawMessage, error) {
+	canon, err := canonicalSigningJSON(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := a.Sign(canon)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(base64.RawStdEncoding.EncodeToString(sig))
+}
+
+// SignedJSON signs the canonical JSON encoding of obj the same way SignJSON
+// does, then returns obj re-encoded with the signature spliced into
+// signatures[userID]["ed25519:"+deviceID], matching the shape Matrix expects
+// for device_keys/one_time_keys uploads.
+func (a Account) SignedJSON(obj any, userID, deviceID string) (json.RawMessage, error) {
+	decoded, err := decodeJSONObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures, _ := decoded["signatures"].(map[string]any)
+	unsigned, hasUnsigned := decoded["unsigned"]
+
+	delete(decoded, "signatures")
+	delete(decoded, "unsigned")
+
+	canon, err := canonicalJSON(decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := a.Sign(canon)
+	if err != nil {
+		return nil, err
+	}
+
+	if signatures == nil {
+		signatures = make(map[string]any)
+	}
+
+	userSigs, _ := signatures[userID].(map[string]any)
+	if userSigs == nil {
+		userSigs = make(map[string]any)
+	}
+
+	userSigs["ed25519:"+deviceID] = base64.RawStdEncoding.EncodeToString(sig)
+	signatures[userID] = userSigs
+	decoded["signatures"] = signatures
+
+	if hasUnsigned {
+		decoded["unsigned"] = unsigned
+	}
+
+	return json.Marshal(decoded)
+}
+
+// canonicalSigningJSON decodes obj, strips "signatures"/"unsigned", and
+// returns the canonical JSON encoding of what's left.
+func canonicalSigningJSON(obj any) ([]byte, error) {
+	decoded, err := decodeJSONObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(decoded, "signatures")
+	delete(decoded, "unsigned")
+
+	return canonicalJSON(decoded)
+}
+
+// decodeJSONObject marshals obj and decodes it back into a map, preserving
+// number formatting via json.Number so re-encoding doesn't introduce floats.
+func decodeJSONObject(obj any) (map[string]any, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var decoded map[string]any
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("olm: SignJSON requires a JSON object: %w", err)
+	}
+
+	return decoded, nil
+}
+
+// canonicalJSON renders v (as produced by a json.Decoder with UseNumber) in
+// RFC 8785-style canonical form: object keys sorted, no insignificant
+// whitespace, and no HTML-escaping of characters like '<', '>' and '&'.
+func canonicalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			kb, err := canonicalString(k)
+			if err != nil {
+				return err
+			}
+			buf.Write(kb)
+			buf.WriteByte(':')
+
+			if err := writeCanonicalJSON(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case json.Number:
+		if _, err := val.Int64(); err != nil {
+			return fmt.Errorf("olm: canonical JSON numbers must be integers, got %q", val.String())
+		}
+		buf.WriteString(val.String())
+
+	case string:
+		sb, err := canonicalString(val)
+		if err != nil {
+			return err
+		}
+		buf.Write(sb)
+
+	case bool:
+		if val {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+
+	case nil:
+		buf.WriteString("null")
+
+	default:
+		return fmt.Errorf("olm: unsupported canonical JSON value of type %T", v)
+	}
+
+	return nil
+}
+
+// canonicalString JSON-encodes s without HTML-escaping or a trailing
+// newline, matching canonical JSON's "no unicode escapes for BMP characters"
+// rule.
+func canonicalString(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+
+	// encoding/json escapes U+2028 and U+2029 even with HTML escaping off,
+	// so write those two back out as literal characters.
+	raw := bytes.TrimRight(buf.Bytes(), "\n")
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			out = append(out, raw[i])
+			continue
+		}
+		if i+6 <= len(raw) && (string(raw[i:i+6]) == "\\u2028" || string(raw[i:i+6]) == "\\u2029") {
+			out = append(out, string(rune(0x2028+int(raw[i+5]-'8')))...)
+			i += 5
+			continue
+		}
+		out = append(out, raw[i], raw[i+1])
+		i++
+	}
+
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package olm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalJSONOrdersKeysAndStripsWhitespace(t *testing.T) {
+	out, err := canonicalJSON(map[string]any{"b": json.Number("1"), "a": "two"})
+	require.Nil(t, err)
+	require.Equal(t, `{"a":"two","b":1}`, string(out))
+}
+
+func TestCanonicalJSONRejectsNonIntegerNumbers(t *testing.T) {
+	_, err := canonicalJSON(map[string]any{"a": json.Number("1.5")})
+	require.NotNil(t, err)
+}
+
+func TestAccountSignedJSONSplicesSignature(t *testing.T) {
+	acc, err := NewAccount()
+	require.Nil(t, err)
+
+	obj := map[string]any{
+		"algorithms": []any{"m.olm.v1.curve25519-aes-sha2"},
+		"user_id":    "@alice:example.org",
+	}
+
+	signed, err := acc.SignedJSON(obj, "@alice:example.org", "DEVICEID")
+	require.Nil(t, err)
+
+	var decoded map[string]any
+	require.Nil(t, json.Unmarshal(signed, &decoded))
+
+	sigs := decoded["signatures"].(map[string]any)
+	userSigs := sigs["@alice:example.org"].(map[string]any)
+	require.Contains(t, userSigs, "ed25519:DEVICEID")
+}
@@ -0,0 +1,67 @@
+//go:build !goolm
+
+package olm
+
+/*
+#include <olm/olm.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"runtime"
+	"unsafe"
+)
+
+// Session holds an olm session's ratchet state. Establishing sessions
+// (NewOutboundSession/NewInboundSession) isn't implemented in this tree yet,
+// so this currently only exists to give Account.RemoveOneTimeKeys something
+// to key off and to carry the same Clear/finalizer lifecycle as Account.
+type Session struct {
+	ptr *C.struct_OlmSession
+
+	// buf is the backing memory olm_session() carved ptr out of. It's kept
+	// here (rather than just discarded after newSession) so Clear has
+	// something to zero once the session is no longer needed.
+	buf []byte
+}
+
+func newSession() *Session {
+	buf := make([]byte, C.olm_session_size())
+
+	sess := &Session{
+		ptr: C.olm_session(unsafe.Pointer(&buf[0])),
+		buf: buf,
+	}
+
+	runtime.SetFinalizer(sess, (*Session).Clear)
+
+	return sess
+}
+
+// Clear wipes the session's secret key material from memory and releases
+// the libolm-side state. It is safe to call more than once. A finalizer
+// calls this automatically as a safety net, but long-lived processes should
+// call it explicitly as soon as a session is no longer needed rather than
+// waiting on the garbage collector.
+func (s *Session) Clear() error {
+	if s.ptr == nil {
+		return nil
+	}
+
+	C.olm_clear_session(s.ptr)
+	err := s.lastError()
+
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+
+	s.ptr = nil
+	runtime.SetFinalizer(s, nil)
+
+	return err
+}
+
+func (s *Session) lastError() error {
+	errStr := C.GoString(C.olm_session_last_error(s.ptr))
+	return Error(errStr)
+}
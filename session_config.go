@@ -0,0 +1,64 @@
+package olm
+
+import "fmt"
+
+// SessionConfig selects which Olm protocol version a session uses. Version 1
+// is the original Olm wire format (truncated 8-byte per-message MACs, no
+// fallback keys); version 2 adds full MACs and fallback-key support. Once
+// session creation exists in this tree it should default to v2, but decoding
+// pre-key messages from very old peers will still require v1.
+//
+// This type is not wired into anything yet: this tree has no
+// NewOutboundSession/NewInboundSession (Session exists only as a stub with a
+// Clear/finalizer lifecycle) for it to configure, so today it only carries
+// and (de)serialises the selected
+// version in isolation. Treat it as plumbing laid down ahead of session
+// creation landing, not as that feature itself.
+type SessionConfig struct {
+	version uint8
+}
+
+// SessionConfigV1 selects the original Olm v1 wire format.
+func SessionConfigV1() SessionConfig {
+	return SessionConfig{version: 1}
+}
+
+// SessionConfigV2 selects the Olm v2 wire format: full per-message MACs and
+// fallback-key support.
+func SessionConfigV2() SessionConfig {
+	return SessionConfig{version: 2}
+}
+
+// DefaultSessionConfig is the config outbound sessions should use unless a
+// peer is known to require the older v1 format.
+func DefaultSessionConfig() SessionConfig {
+	return SessionConfigV2()
+}
+
+// Version returns the protocol version this config selects, 1 or 2.
+func (c SessionConfig) Version() int {
+	return int(c.version)
+}
+
+// pickleByte encodes the config as the single version byte libolm's session
+// pickle format stores it as.
+func (c SessionConfig) pickleByte() (byte, error) {
+	switch c.version {
+	case 1, 2:
+		return c.version, nil
+	default:
+		return 0, fmt.Errorf("olm: unknown session config version %d", c.version)
+	}
+}
+
+// sessionConfigFromPickleByte decodes the version byte written by pickleByte.
+func sessionConfigFromPickleByte(b byte) (SessionConfig, error) {
+	switch b {
+	case 1:
+		return SessionConfigV1(), nil
+	case 2:
+		return SessionConfigV2(), nil
+	default:
+		return SessionConfig{}, fmt.Errorf("olm: unknown session pickle version %d", b)
+	}
+}
@@ -0,0 +1,29 @@
+package olm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionConfigVersions(t *testing.T) {
+	require.Equal(t, 1, SessionConfigV1().Version())
+	require.Equal(t, 2, SessionConfigV2().Version())
+	require.Equal(t, SessionConfigV2(), DefaultSessionConfig())
+}
+
+func TestSessionConfigPickleByteRoundTrip(t *testing.T) {
+	for _, cfg := range []SessionConfig{SessionConfigV1(), SessionConfigV2()} {
+		b, err := cfg.pickleByte()
+		require.Nil(t, err)
+
+		decoded, err := sessionConfigFromPickleByte(b)
+		require.Nil(t, err)
+		require.Equal(t, cfg, decoded)
+	}
+}
+
+func TestSessionConfigFromPickleByteUnknown(t *testing.T) {
+	_, err := sessionConfigFromPickleByte(9)
+	require.NotNil(t, err)
+}
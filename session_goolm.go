@@ -0,0 +1,34 @@
+//go:build goolm
+
+package olm
+
+import "runtime"
+
+// Session is the pure-Go equivalent of the cgo/libolm backed Session. The
+// double ratchet itself (NewOutboundSession/NewInboundSession, Encrypt,
+// Decrypt) isn't implemented in this tree yet, so this currently only exists
+// to give Account.RemoveOneTimeKeys something to key off and to carry the
+// same Clear/finalizer lifecycle as Account. There is no secret state to
+// scrub yet; Clear just marks the session closed.
+type Session struct {
+	closed bool
+}
+
+func newSession() *Session {
+	sess := &Session{}
+
+	runtime.SetFinalizer(sess, (*Session).Clear)
+
+	return sess
+}
+
+// Clear releases the session. It is safe to call more than once. A
+// finalizer calls this automatically as a safety net, but long-lived
+// processes should call it explicitly as soon as a session is no longer
+// needed rather than waiting on the garbage collector.
+func (s *Session) Clear() error {
+	s.closed = true
+	runtime.SetFinalizer(s, nil)
+
+	return nil
+}